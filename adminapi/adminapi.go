@@ -0,0 +1,183 @@
+// Package adminapi exposes dsnet's report and peer state over a local
+// socket, in the spirit of Tailscale's DebugMux: a small HTTP surface that
+// lets operators inspect and mutate a running daemon without shelling into
+// the host or re-parsing the JSON report written to disk.
+package adminapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/herzenschein/dsnet"
+)
+
+// ErrPeerNotFound is returned by Server.RemovePeer and reported as a 404 by
+// the HTTP handlers when a hostname doesn't match any configured peer.
+var ErrPeerNotFound = errors.New("adminapi: peer not found")
+
+// Server answers admin requests against a running dsnet daemon. All three
+// funcs must be set; they're expected to be wired to the daemon's live
+// config/wgctrl state so responses always reflect what's currently running.
+type Server struct {
+	// GetReport returns the most recently generated report.
+	GetReport func() *dsnet.DsnetReport
+	// AddPeer adds peer to the running config and wireguard device without
+	// a full reload, returning the resulting report.
+	AddPeer func(peer dsnet.Peer) (*dsnet.DsnetReport, error)
+	// RemovePeer removes the peer with the given hostname. It returns
+	// ErrPeerNotFound if no such peer is configured.
+	RemovePeer func(hostname string) error
+}
+
+// Handler builds the full mux for this server, including mutating peer
+// endpoints and /debug/pprof/*. Only serve this over the unix socket: it
+// has no authentication of its own, and pprof alone is enough to make a
+// world-reachable TCP listener a liability.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/report", s.handleReport)
+	mux.HandleFunc("/peers", s.handlePeers)
+	mux.HandleFunc("/peers/", s.handlePeer)
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return mux
+}
+
+// reportOnlyHandler builds a read-only mux: just GET /report. This is what
+// ListenAndServe binds to tcpAddr, since a TCP listener is reachable off-
+// host and this package has no auth/TLS of its own to protect mutation or
+// pprof with.
+func (s *Server) reportOnlyHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/report", s.handleReport)
+	return mux
+}
+
+// ListenAndServe serves the admin API on unixSocket, tcpAddr, or both.
+// Either may be empty to disable that listener. unixSocket gets the full
+// mux (chmod'd 0600 so only the daemon's own user can reach it); tcpAddr
+// gets the restricted report-only mux, since it's the one an operator
+// might accidentally expose beyond loopback. It blocks until one of the
+// listeners returns an error.
+func (s *Server) ListenAndServe(unixSocket, tcpAddr string) error {
+	if unixSocket == "" && tcpAddr == "" {
+		return errors.New("adminapi: at least one of unixSocket or tcpAddr is required")
+	}
+
+	errs := make(chan error, 2)
+
+	if unixSocket != "" {
+		l, err := net.Listen("unix", unixSocket)
+		if err != nil {
+			return err
+		}
+		if err := os.Chmod(unixSocket, 0600); err != nil {
+			return err
+		}
+		go func() { errs <- http.Serve(l, s.Handler()) }()
+	}
+
+	if tcpAddr != "" {
+		l, err := net.Listen("tcp", tcpAddr)
+		if err != nil {
+			return err
+		}
+		go func() { errs <- http.Serve(l, s.reportOnlyHandler()) }()
+	}
+
+	return <-errs
+}
+
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.GetReport())
+}
+
+// handlePeers serves POST /peers (add a peer).
+func (s *Server) handlePeers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var peer dsnet.Peer
+	if err := json.NewDecoder(r.Body).Decode(&peer); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Enforce the validate tags on dsnet.Peer, so a POST can't add a peer
+	// missing a hostname, IP or public key. WithRequiredStructEnabled makes
+	// "required" apply to the PublicKey struct too. LoadConfig does not run
+	// these per-peer checks on peers from the config file.
+	if err := validator.New(validator.WithRequiredStructEnabled()).Struct(peer); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := s.AddPeer(peer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, report)
+}
+
+// handlePeer serves GET/DELETE /peers/{hostname}.
+func (s *Server) handlePeer(w http.ResponseWriter, r *http.Request) {
+	hostname := strings.TrimPrefix(r.URL.Path, "/peers/")
+	if hostname == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		report := s.GetReport()
+		for _, peer := range report.Peers {
+			if peer.Hostname == hostname {
+				writeJSON(w, http.StatusOK, peer)
+				return
+			}
+		}
+		http.NotFound(w, r)
+
+	case http.MethodDelete:
+		err := s.RemovePeer(hostname)
+		if errors.Is(err, ErrPeerNotFound) {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
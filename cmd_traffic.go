@@ -0,0 +1,29 @@
+package dsnet
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/herzenschein/dsnet/netlog"
+)
+
+// CmdTraffic implements `dsnet traffic <hostname>`: it renders the
+// netlog-derived traffic windows for one peer as a table.
+func CmdTraffic(out io.Writer, netlogDir, hostname string) error {
+	windows, err := netlog.Aggregate(netlogDir, hostname, time.Now())
+	if err != nil {
+		return fmt.Errorf("aggregating traffic for %s: %w", hostname, err)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "WINDOW\tBYTES/SEC\tPACKETS/SEC")
+
+	for _, label := range []string{"1m", "5m", "1h"} {
+		w := windows[label]
+		fmt.Fprintf(tw, "%s\t%s\t%.0f\n", label, BytesToSI(int64(w.BytesPerSecond)), w.PacketsPerSecond)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,93 @@
+package dsnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// PublicKey wraps wgtypes.Key so it can be marshalled to/from the base64
+// representation used throughout the wireguard tooling.
+type PublicKey struct {
+	Key wgtypes.Key
+}
+
+func (k PublicKey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k.Key.String())
+}
+
+func (k *PublicKey) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	key, err := wgtypes.ParseKey(s)
+	if err != nil {
+		return err
+	}
+
+	k.Key = key
+	return nil
+}
+
+// JSONIPNet wraps net.IPNet so it can be marshalled to/from its CIDR string
+// representation.
+type JSONIPNet net.IPNet
+
+func (n JSONIPNet) MarshalJSON() ([]byte, error) {
+	ipnet := net.IPNet(n)
+	return json.Marshal(ipnet.String())
+}
+
+func (n *JSONIPNet) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	_, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return fmt.Errorf("parsing network %q: %w", s, err)
+	}
+
+	*n = JSONIPNet(*ipnet)
+	return nil
+}
+
+// Peer is a single host configured to join the dsnet wireguard network.
+type Peer struct {
+	PublicKey   PublicKey `validate:"required"`
+	Hostname    string    `validate:"required,hostname_rfc1123"`
+	Owner       string
+	Description string
+	Added       time.Time
+	IP          net.IP        `validate:"required"`
+	Networks    []JSONIPNet
+	// Persistent marks this peer as a server dsnet should keep a handshake
+	// alive with, re-resolving Endpoint and reconnecting when it goes
+	// offline rather than waiting for it to dial in.
+	Persistent bool
+	// Endpoint is where to reach this peer: an "ip:port" or a DNS name, eg
+	// "vpn.example.com:51820". It is not resolved at config load; the
+	// persistent-peer reconciler resolves it on every reconnect attempt.
+	// Only meaningful when Persistent is set.
+	Endpoint string
+}
+
+// DsnetConfig is the full on-disk configuration for a dsnet server,
+// including every configured peer.
+type DsnetConfig struct {
+	ExternalIP    net.IP `validate:"required"`
+	InterfaceName string `validate:"required"`
+	ListenPort    int    `validate:"required"`
+	PrivateKey    PublicKey
+	Domain        string
+	IP            net.IP    `validate:"required"`
+	Network       JSONIPNet `validate:"required"`
+	DNS           net.IP
+	Peers         []Peer
+}
@@ -0,0 +1,117 @@
+package dsnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/hjson/hjson-go/v4"
+	"gopkg.in/yaml.v3"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// LoadConfig reads and parses CONFIG_FILE, detecting whether it's JSON,
+// HJSON or YAML the same way Yggdrasil's readConfig does: try each parser
+// in turn rather than trusting the file extension, since operators hand-edit
+// these files and don't always get the suffix right.
+func LoadConfig() (*DsnetConfig, error) {
+	raw, err := ioutil.ReadFile(CONFIG_FILE)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", CONFIG_FILE, err)
+	}
+
+	conf := &DsnetConfig{}
+	if err := unmarshalConfig(raw, conf); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", CONFIG_FILE, err)
+	}
+
+	if err := validator.New().Struct(conf); err != nil {
+		return nil, err
+	}
+
+	return conf, nil
+}
+
+// MustLoadConfig is LoadConfig for call sites that can't meaningfully
+// recover from a bad config, eg daemon startup.
+func MustLoadConfig() *DsnetConfig {
+	conf, err := LoadConfig()
+	check(err)
+	return conf
+}
+
+// unmarshalConfig tries JSON first since it's the strict, canonical format
+// written by -normaliseconf, then falls back to the more forgiving HJSON
+// (which accepts comments and trailing commas) and finally YAML. Each
+// attempt decodes into its own zero-value config so a failed parser can't
+// leave partially-decoded fields behind for the next one to build on.
+func unmarshalConfig(raw []byte, conf *DsnetConfig) error {
+	var parsed DsnetConfig
+
+	jsonErr := json.Unmarshal(raw, &parsed)
+	if jsonErr == nil {
+		*conf = parsed
+		return nil
+	}
+
+	parsed = DsnetConfig{}
+	hjsonErr := hjson.Unmarshal(raw, &parsed)
+	if hjsonErr == nil {
+		*conf = parsed
+		return nil
+	}
+
+	parsed = DsnetConfig{}
+	if yamlErr := unmarshalYAMLConfig(raw, &parsed); yamlErr == nil {
+		*conf = parsed
+		return nil
+	}
+
+	return fmt.Errorf("not valid JSON (%s), HJSON (%s), or YAML", jsonErr, hjsonErr)
+}
+
+// unmarshalYAMLConfig decodes YAML by first decoding into a generic
+// map[string]interface{} and re-marshalling to JSON, rather than decoding
+// directly into conf. yaml.v3 doesn't know about the json.Marshaler /
+// json.Unmarshaler implementations on PublicKey, JSONIPNet and net.IP, so a
+// direct decode would silently leave those fields zero instead of erroring;
+// going via JSON reuses the same decoding logic every other format already
+// relies on.
+func unmarshalYAMLConfig(raw []byte, conf *DsnetConfig) error {
+	var generic interface{}
+	if err := yaml.Unmarshal(raw, &generic); err != nil {
+		return err
+	}
+
+	asJSON, err := json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(asJSON, conf)
+}
+
+// MustNormaliseConfig loads CONFIG_FILE in whatever format it's currently
+// in and rewrites it as canonical indented JSON. This backs the
+// `-normaliseconf` flag: a one-shot way to migrate a hand-edited HJSON/YAML
+// config back to the format dsnet itself writes.
+func MustNormaliseConfig() {
+	conf := MustLoadConfig()
+	check(SaveConfig(conf))
+}
+
+// SaveConfig writes conf back to CONFIG_FILE as canonical indented JSON.
+// Anything that mutates a config already loaded into memory (eg the
+// daemon's admin-triggered AddPeer/RemovePeer) must call this: the SIGHUP
+// reloader diffs a fresh LoadConfig() against the in-memory config on
+// every reload, so an in-memory-only change is invisible to it and gets
+// silently reverted on the next reload.
+func SaveConfig(conf *DsnetConfig) error {
+	raw, err := json.MarshalIndent(conf, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(CONFIG_FILE, raw, 0644)
+}
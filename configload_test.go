@@ -0,0 +1,72 @@
+package dsnet
+
+import "testing"
+
+const testPublicKey = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+
+func TestUnmarshalConfigFormats(t *testing.T) {
+	cases := map[string]string{
+		"json": `{
+			"ExternalIP": "203.0.113.1",
+			"InterfaceName": "dsnet0",
+			"ListenPort": 51820,
+			"IP": "10.0.0.1",
+			"Network": "10.0.0.0/24",
+			"Peers": [{
+				"PublicKey": "` + testPublicKey + `",
+				"Hostname": "server1",
+				"IP": "10.0.0.2"
+			}]
+		}`,
+		"hjson": `{
+			// comments and trailing commas are HJSON-only
+			ExternalIP: 203.0.113.1
+			InterfaceName: dsnet0
+			ListenPort: 51820
+			IP: 10.0.0.1
+			Network: 10.0.0.0/24
+			Peers: [
+				{
+					PublicKey: ` + testPublicKey + `
+					Hostname: server1
+					IP: 10.0.0.2
+				},
+			]
+		}`,
+		"yaml": `
+ExternalIP: 203.0.113.1
+InterfaceName: dsnet0
+ListenPort: 51820
+IP: 10.0.0.1
+Network: 10.0.0.0/24
+Peers:
+  - PublicKey: ` + testPublicKey + `
+    Hostname: server1
+    IP: 10.0.0.2
+`,
+	}
+
+	for format, raw := range cases {
+		t.Run(format, func(t *testing.T) {
+			conf := &DsnetConfig{}
+			if err := unmarshalConfig([]byte(raw), conf); err != nil {
+				t.Fatalf("unmarshalConfig: %s", err)
+			}
+
+			if conf.InterfaceName != "dsnet0" {
+				t.Errorf("InterfaceName = %q, want dsnet0", conf.InterfaceName)
+			}
+			if len(conf.Peers) != 1 || conf.Peers[0].Hostname != "server1" {
+				t.Errorf("Peers = %+v, want one peer named server1", conf.Peers)
+			}
+		})
+	}
+}
+
+func TestUnmarshalConfigInvalid(t *testing.T) {
+	conf := &DsnetConfig{}
+	err := unmarshalConfig([]byte("not json, hjson, or yaml: [[["), conf)
+	if err == nil {
+		t.Fatal("unmarshalConfig: expected an error for garbage input")
+	}
+}
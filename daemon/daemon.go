@@ -0,0 +1,340 @@
+// Package daemon wires the adminapi, metrics, persistent-peer reconciler,
+// config reloader and netlog subsystems to one running wireguard device
+// and config. It's the entrypoint a dsnet daemon binary is meant to start
+// those subsystems from, rather than leaving them as unreachable library
+// code.
+package daemon
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+	"github.com/herzenschein/dsnet"
+	"github.com/herzenschein/dsnet/adminapi"
+	"github.com/herzenschein/dsnet/metrics"
+	"github.com/herzenschein/dsnet/netlog"
+)
+
+// reportRefreshInterval is how often Run regenerates the cached report in
+// the background, so GetReport (and everything reading through it: the
+// adminapi /report endpoint, the Prometheus exporter, the persistent-peer
+// reconciler) stays live instead of only advancing on the next mutation or
+// SIGHUP reload.
+const reportRefreshInterval = 30 * time.Second
+
+// Daemon owns the live config/report state for one running wireguard
+// device.
+type Daemon struct {
+	Client        *wgctrl.Client
+	InterfaceName string
+	// NetlogDir enables traffic sampling/aggregation; empty disables it.
+	NetlogDir string
+
+	mu     sync.RWMutex
+	conf   *dsnet.DsnetConfig
+	report *dsnet.DsnetReport
+}
+
+// New loads the current config and generates an initial report for
+// interfaceName.
+func New(client *wgctrl.Client, interfaceName, netlogDir string) (*Daemon, error) {
+	conf, err := dsnet.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+
+	dev, err := client.Device(interfaceName)
+	if err != nil {
+		return nil, fmt.Errorf("reading device: %w", err)
+	}
+
+	report := dsnet.GenerateReport(dev, conf, nil, netlogDir)
+
+	return &Daemon{
+		Client:        client,
+		InterfaceName: interfaceName,
+		NetlogDir:     netlogDir,
+		conf:          conf,
+		report:        &report,
+	}, nil
+}
+
+func (d *Daemon) GetConfig() *dsnet.DsnetConfig {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.conf
+}
+
+func (d *Daemon) SetConfig(conf *dsnet.DsnetConfig) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.conf = conf
+}
+
+func (d *Daemon) GetReport() *dsnet.DsnetReport {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.report
+}
+
+func (d *Daemon) SetReport(report *dsnet.DsnetReport) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.report = report
+}
+
+// SetLastResolution records a persistent peer's most recent DNS
+// resolution on the cached report, so it survives until the next
+// regenerate. It's wired to PersistentPeerReconciler.OnResolve.
+//
+// Like SetReport, this publishes a new *dsnet.DsnetReport rather than
+// mutating d.report's fields in place: GetReport callers only hold d.mu
+// for the duration of the pointer read, so a caller that's already read
+// the old pointer may still be reading its Peers fields with no lock
+// held at all. Mutating the shared report in place would race with that.
+func (d *Daemon) SetLastResolution(hostname string, resolvedIP net.IP, at time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	peers := make([]dsnet.PeerReport, len(d.report.Peers))
+	copy(peers, d.report.Peers)
+
+	for i, peer := range peers {
+		if peer.Hostname == hostname {
+			peers[i].LastResolutionTime = at
+			peers[i].LastResolvedIP = resolvedIP
+			break
+		}
+	}
+
+	report := *d.report
+	report.Peers = peers
+	d.report = &report
+}
+
+// Hostnames maps each configured peer's public key to its hostname, for
+// netlog.Sampler to key log files by.
+func (d *Daemon) Hostnames() map[string]string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	hostnames := make(map[string]string, len(d.conf.Peers))
+	for _, peer := range d.conf.Peers {
+		hostnames[peer.PublicKey.Key.String()] = peer.Hostname
+	}
+	return hostnames
+}
+
+// AddPeer implements adminapi.Server's AddPeer hook.
+func (d *Daemon) AddPeer(peer dsnet.Peer) (*dsnet.DsnetReport, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, existing := range d.conf.Peers {
+		if existing.Hostname == peer.Hostname {
+			return nil, fmt.Errorf("peer %s already exists", peer.Hostname)
+		}
+	}
+
+	if peer.Added.IsZero() {
+		peer.Added = time.Now()
+	}
+
+	err := d.Client.ConfigureDevice(d.InterfaceName, wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{{PublicKey: peer.PublicKey.Key, AllowedIPs: dsnet.PeerAllowedIPs(peer)}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("configuring device: %w", err)
+	}
+
+	d.conf.Peers = append(d.conf.Peers, peer)
+
+	// Persist immediately: the SIGHUP reloader diffs a fresh LoadConfig()
+	// against this in-memory config on every reload, so without this the
+	// very next SIGHUP would see the added peer as absent from disk and
+	// remove it again.
+	if err := dsnet.SaveConfig(d.conf); err != nil {
+		return nil, fmt.Errorf("saving config: %w", err)
+	}
+
+	return d.regenerateLocked()
+}
+
+// RemovePeer implements adminapi.Server's RemovePeer hook.
+func (d *Daemon) RemovePeer(hostname string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	idx := -1
+	var publicKey wgtypes.Key
+	for i, peer := range d.conf.Peers {
+		if peer.Hostname == hostname {
+			idx = i
+			publicKey = peer.PublicKey.Key
+			break
+		}
+	}
+	if idx == -1 {
+		return adminapi.ErrPeerNotFound
+	}
+
+	err := d.Client.ConfigureDevice(d.InterfaceName, wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{{PublicKey: publicKey, Remove: true}},
+	})
+	if err != nil {
+		return fmt.Errorf("configuring device: %w", err)
+	}
+
+	d.conf.Peers = append(d.conf.Peers[:idx], d.conf.Peers[idx+1:]...)
+
+	// Persist immediately; see the matching comment in AddPeer.
+	if err := dsnet.SaveConfig(d.conf); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+
+	_, err = d.regenerateLocked()
+	return err
+}
+
+// regenerateLocked rebuilds the report from the live device. Callers must
+// hold d.mu.
+func (d *Daemon) regenerateLocked() (*dsnet.DsnetReport, error) {
+	dev, err := d.Client.Device(d.InterfaceName)
+	if err != nil {
+		return nil, fmt.Errorf("reading device: %w", err)
+	}
+
+	report := dsnet.GenerateReport(dev, d.conf, d.report, d.NetlogDir)
+	d.report = &report
+	return d.report, nil
+}
+
+// refreshReportPeriodically regenerates the cached report every
+// reportRefreshInterval until stop is closed, so status/handshake/traffic
+// fields advance on their own between peer mutations and SIGHUP reloads.
+func (d *Daemon) refreshReportPeriodically(stop <-chan struct{}) {
+	ticker := time.NewTicker(reportRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			d.mu.Lock()
+			_, err := d.regenerateLocked()
+			d.mu.Unlock()
+			if err != nil {
+				log.Printf("dsnet: refreshing report: %s", err)
+			}
+		}
+	}
+}
+
+// Run starts every subsystem and blocks until stop is closed or one of
+// them returns an error. unixSocket/tcpAddr/metricsAddr may be empty to
+// disable that listener; NetlogDir empty disables traffic sampling.
+func (d *Daemon) Run(stop <-chan struct{}, unixSocket, tcpAddr, metricsAddr string) error {
+	admin := &adminapi.Server{
+		GetReport:  d.GetReport,
+		AddPeer:    d.AddPeer,
+		RemovePeer: d.RemovePeer,
+	}
+
+	reconciler := &dsnet.PersistentPeerReconciler{
+		Client:        d.Client,
+		InterfaceName: d.InterfaceName,
+		GetConfig:     d.GetConfig,
+		GetReport:     d.GetReport,
+		OnResolve:     d.SetLastResolution,
+	}
+
+	reloader := &dsnet.ConfigReloader{
+		Client:        d.Client,
+		InterfaceName: d.InterfaceName,
+		GetConfig:     d.GetConfig,
+		SetConfig:     d.SetConfig,
+		GetReport:     d.GetReport,
+		SetReport:     d.SetReport,
+		NetlogDir:     d.NetlogDir,
+	}
+
+	exporter := metrics.NewExporter(d.GetReport)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+
+	// The admin and metrics listeners don't watch stop, so they aren't
+	// added to wg: waiting on them would keep Run from returning once stop
+	// is closed. They keep serving until the process exits.
+	if unixSocket != "" || tcpAddr != "" {
+		go func() {
+			if err := admin.ListenAndServe(unixSocket, tcpAddr); err != nil {
+				errs <- fmt.Errorf("adminapi: %w", err)
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		reconciler.Run(stop)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.refreshReportPeriodically(stop)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		reloader.WatchSIGHUP(stop)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		exporter.Run(stop)
+	}()
+
+	if metricsAddr != "" {
+		go func() {
+			if err := exporter.ListenAndServe(metricsAddr); err != nil {
+				errs <- fmt.Errorf("metrics: %w", err)
+			}
+		}()
+	}
+
+	if d.NetlogDir != "" {
+		sampler := &netlog.Sampler{
+			Client:        d.Client,
+			InterfaceName: d.InterfaceName,
+			Dir:           d.NetlogDir,
+			Hostnames:     d.Hostnames,
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sampler.Run(stop); err != nil {
+				errs <- fmt.Errorf("netlog: %w", err)
+			}
+		}()
+	}
+
+	select {
+	case err := <-errs:
+		return err
+	case <-stop:
+		wg.Wait()
+		return nil
+	}
+}
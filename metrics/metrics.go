@@ -0,0 +1,166 @@
+// Package metrics publishes dsnet's report as Prometheus/OpenMetrics
+// metrics, giving the same observability Tailscale and sing-box expose via
+// their debug/clash APIs, using the data GenerateReport already computes.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/herzenschein/dsnet"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// sampleInterval is how often the handshake-age histogram is sampled. The
+// gauges and counters below are collected on every Prometheus scrape
+// instead, since they're cheap to recompute from the latest report.
+const sampleInterval = time.Minute
+
+// peerLabels identifies a peer stably across scrapes. external_ip is
+// deliberately not in here: PersistentPeerReconciler re-resolves a
+// persistent peer's endpoint on every reconnect, so keying a counter or
+// histogram series on it would orphan the old series (breaking
+// rate()/increase()) every time DNS churns. It's still reported, just as a
+// label on the info-only dsnet_peer_info gauge below.
+var peerLabels = []string{"hostname", "owner"}
+
+var peerInfoLabels = []string{"hostname", "owner", "external_ip"}
+
+// Exporter is a prometheus.Collector backed by a dsnet report. Register it
+// with a prometheus.Registry, or use ListenAndServe for a standalone
+// /metrics endpoint.
+type Exporter struct {
+	// GetReport returns the most recently generated report.
+	GetReport func() *dsnet.DsnetReport
+
+	peerInfo              *prometheus.Desc
+	peerOnline            *prometheus.Desc
+	peersTotal            *prometheus.Desc
+	peersOnline           *prometheus.Desc
+	receiveBytes          *prometheus.Desc
+	transmitBytes         *prometheus.Desc
+	secondsSinceHandshake *prometheus.HistogramVec
+}
+
+// NewExporter builds an Exporter. Call Run to start sampling the handshake
+// histogram, and register the returned Exporter with a prometheus.Registry
+// (or prometheus.MustRegister it) to expose the rest.
+func NewExporter(getReport func() *dsnet.DsnetReport) *Exporter {
+	return &Exporter{
+		GetReport: getReport,
+
+		peerInfo: prometheus.NewDesc(
+			"dsnet_peer_info", "Always 1; external_ip carries the peer's last known external IP as a label, since it's too mutable to key other series on.",
+			peerInfoLabels, nil,
+		),
+		peerOnline: prometheus.NewDesc(
+			"dsnet_peer_online", "Whether a peer currently has a live handshake (1) or not (0).",
+			peerLabels, nil,
+		),
+		peersTotal: prometheus.NewDesc(
+			"dsnet_peers_total", "Total number of configured peers.",
+			nil, nil,
+		),
+		peersOnline: prometheus.NewDesc(
+			"dsnet_peers_online", "Number of peers with a live handshake.",
+			nil, nil,
+		),
+		receiveBytes: prometheus.NewDesc(
+			"dsnet_peer_receive_bytes_total", "Bytes received from this peer, as reported by wireguard.",
+			peerLabels, nil,
+		),
+		transmitBytes: prometheus.NewDesc(
+			"dsnet_peer_transmit_bytes_total", "Bytes transmitted to this peer, as reported by wireguard.",
+			peerLabels, nil,
+		),
+		secondsSinceHandshake: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "dsnet_peer_seconds_since_handshake",
+			Help:    "Seconds since the last handshake with this peer, sampled every " + sampleInterval.String() + ".",
+			Buckets: []float64{10, 30, 60, 180, 300, 900, 3600, 86400, 28 * 86400},
+		}, peerLabels),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- e.peerInfo
+	ch <- e.peerOnline
+	ch <- e.peersTotal
+	ch <- e.peersOnline
+	ch <- e.receiveBytes
+	ch <- e.transmitBytes
+	e.secondsSinceHandshake.Describe(ch)
+}
+
+// Collect implements prometheus.Collector, recomputing every metric from
+// the latest report on each scrape.
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	report := e.GetReport()
+	if report == nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(e.peersTotal, prometheus.GaugeValue, float64(report.PeersTotal))
+	ch <- prometheus.MustNewConstMetric(e.peersOnline, prometheus.GaugeValue, float64(report.PeersOnline))
+
+	for _, peer := range report.Peers {
+		labels := []string{peer.Hostname, peer.Owner}
+
+		ch <- prometheus.MustNewConstMetric(e.peerInfo, prometheus.GaugeValue, 1, peer.Hostname, peer.Owner, peer.ExternalIP.String())
+
+		online := 0.0
+		if peer.Status == dsnet.StatusOnline {
+			online = 1.0
+		}
+		ch <- prometheus.MustNewConstMetric(e.peerOnline, prometheus.GaugeValue, online, labels...)
+		ch <- prometheus.MustNewConstMetric(e.receiveBytes, prometheus.CounterValue, float64(peer.ReceiveBytes), labels...)
+		ch <- prometheus.MustNewConstMetric(e.transmitBytes, prometheus.CounterValue, float64(peer.TransmitBytes), labels...)
+	}
+
+	e.secondsSinceHandshake.Collect(ch)
+}
+
+// Run samples the handshake-age histogram every sampleInterval until stop
+// is closed. The other metrics need no sampling loop since Collect derives
+// them fresh on every scrape.
+func (e *Exporter) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			e.sample()
+		}
+	}
+}
+
+func (e *Exporter) sample() {
+	report := e.GetReport()
+	if report == nil {
+		return
+	}
+
+	for _, peer := range report.Peers {
+		if peer.LastHandshakeTime.IsZero() {
+			continue
+		}
+
+		e.secondsSinceHandshake.WithLabelValues(peer.Hostname, peer.Owner).Observe(time.Since(peer.LastHandshakeTime).Seconds())
+	}
+}
+
+// ListenAndServe registers e with a fresh registry and serves /metrics on
+// addr. It blocks until the listener returns an error.
+func (e *Exporter) ListenAndServe(addr string) error {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	return http.ListenAndServe(addr, mux)
+}
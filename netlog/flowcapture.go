@@ -0,0 +1,39 @@
+package netlog
+
+import "errors"
+
+// ErrFlowCaptureUnsupported is returned by the default FlowCapture when no
+// platform-specific tap has been wired in.
+var ErrFlowCaptureUnsupported = errors.New("netlog: flow capture not supported on this build")
+
+// FlowCapture is the optional extension point for 5-tuple flow capture on
+// the wireguard interface, eg via an eBPF tc/XDP program or an AF_PACKET
+// socket. Nothing in this package calls it: Sampler and Aggregate work
+// only from the byte counters wgctrl exposes, so Aggregate's
+// PacketsPerSecond is always zero.
+//
+// This package ships no concrete implementation: a real tap needs cgo and
+// root/CAP_NET_ADMIN, and belongs in a platform-specific file (eg
+// flowcapture_linux.go) built only where that's available.
+type FlowCapture interface {
+	// Start begins capturing flows on iface, invoking onFlow for each
+	// observed packet until Stop is called.
+	Start(iface string, onFlow func(Flow)) error
+	Stop() error
+}
+
+// Flow is a single observed packet on the wireguard interface.
+type Flow struct {
+	SourceIP        string
+	DestinationIP   string
+	SourcePort      int
+	DestinationPort int
+	Protocol        string
+	Bytes           int
+}
+
+// NoFlowCapture is the default FlowCapture: it always reports unsupported.
+type NoFlowCapture struct{}
+
+func (NoFlowCapture) Start(iface string, onFlow func(Flow)) error { return ErrFlowCaptureUnsupported }
+func (NoFlowCapture) Stop() error                                 { return nil }
@@ -0,0 +1,278 @@
+// Package netlog samples per-peer traffic counters and writes them to
+// append-only, rotated log files, the way Tailscale's wgengine/netlog
+// tracks flows. It's the on-disk source of truth behind the traffic
+// aggregates surfaced on PeerReport and the `dsnet traffic <hostname>` CLI.
+//
+// On-disk schema
+//
+// Each peer gets one active log file at <dir>/<hostname>.log, newline-
+// delimited JSON, one Sample per line, oldest first:
+//
+//	{"Time":"2024-01-02T15:04:05Z","ReceiveBytes":1234,"TransmitBytes":5678}
+//
+// ReceiveBytes/TransmitBytes are the cumulative wireguard counters at
+// sample time (not deltas), so a reader can always recover a rate between
+// any two samples without needing every sample in between. At midnight UTC
+// the active file is renamed to <dir>/<hostname>-2024-01-02.log.gz (gzip
+// compressed) and a fresh <hostname>.log is started.
+package netlog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+)
+
+// sampleInterval is how often peer counters are sampled and appended.
+const sampleInterval = 10 * time.Second
+
+// Sample is one line of a peer's on-disk traffic log.
+type Sample struct {
+	Time          time.Time
+	ReceiveBytes  int64
+	TransmitBytes int64
+}
+
+// Window is a traffic rate computed over a trailing time span.
+type Window struct {
+	BytesPerSecond   float64
+	PacketsPerSecond float64
+}
+
+// Sampler periodically samples a wireguard device's peer counters and
+// appends them to per-peer log files under Dir.
+type Sampler struct {
+	Client        *wgctrl.Client
+	InterfaceName string
+	Dir           string
+	// Hostnames maps a peer's public key (base64, as wgtypes.Key.String
+	// renders it) to the config hostname that Aggregate/cmd_traffic look
+	// files up by. A peer missing from the map is skipped: base64 keys
+	// routinely contain '/', which would otherwise turn into a bogus
+	// subdirectory under Dir and fail every other peer's sample in the
+	// same pass.
+	Hostnames func() map[string]string
+}
+
+// Run blocks, sampling every sampleInterval until stop is closed.
+func (s *Sampler) Run(stop <-chan struct{}) error {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return fmt.Errorf("creating netlog dir: %w", err)
+	}
+
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			if err := s.sampleOnce(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Sampler) sampleOnce() error {
+	dev, err := s.Client.Device(s.InterfaceName)
+	if err != nil {
+		return fmt.Errorf("reading device: %w", err)
+	}
+
+	now := time.Now()
+	hostnames := s.Hostnames()
+
+	// Rotate before appending: an append refreshes the active file's mtime,
+	// so checking afterwards would never see a file from a previous day.
+	if err := s.rotateIfNeeded(now); err != nil {
+		return err
+	}
+
+	for _, peer := range dev.Peers {
+		hostname, known := hostnames[peer.PublicKey.String()]
+		if !known {
+			// Not in config (or config removed it since this Device read);
+			// nothing to key the log file by.
+			continue
+		}
+
+		sample := Sample{
+			Time:          now,
+			ReceiveBytes:  peer.ReceiveBytes,
+			TransmitBytes: peer.TransmitBytes,
+		}
+
+		if err := s.append(hostname, sample); err != nil {
+			return fmt.Errorf("appending sample for %s: %w", hostname, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Sampler) append(hostname string, sample Sample) error {
+	f, err := os.OpenFile(s.activePath(hostname), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(sample)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+func (s *Sampler) activePath(hostname string) string {
+	return filepath.Join(s.Dir, hostname+".log")
+}
+
+// rotateIfNeeded gzips and renames any active log file that belongs to a
+// previous UTC day.
+func (s *Sampler) rotateIfNeeded(now time.Time) error {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".log" {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		if sameUTCDay(info.ModTime(), now) {
+			continue
+		}
+
+		if err := s.rotate(entry.Name(), info.ModTime()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Sampler) rotate(activeName string, day time.Time) error {
+	hostname := activeName[:len(activeName)-len(".log")]
+	activePath := filepath.Join(s.Dir, activeName)
+	rotatedPath := filepath.Join(s.Dir, fmt.Sprintf("%s-%s.log.gz", hostname, day.UTC().Format("2006-01-02")))
+
+	raw, err := os.ReadFile(activePath)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(rotatedPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	if _, err := gz.Write(raw); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(activePath)
+}
+
+func sameUTCDay(a, b time.Time) bool {
+	a, b = a.UTC(), b.UTC()
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// Aggregate computes 1m/5m/1h traffic windows for hostname from its active
+// log file. PacketsPerSecond is always zero: wgctrl only exposes byte
+// counters, and Aggregate does not use a FlowCapture (see flowcapture.go).
+func Aggregate(dir, hostname string, now time.Time) (map[string]Window, error) {
+	samples, err := readSamples(filepath.Join(dir, hostname+".log"))
+	if err != nil {
+		return nil, err
+	}
+
+	spans := map[string]time.Duration{
+		"1m": time.Minute,
+		"5m": 5 * time.Minute,
+		"1h": time.Hour,
+	}
+
+	windows := make(map[string]Window, len(spans))
+	for label, span := range spans {
+		windows[label] = windowFor(samples, now, span)
+	}
+
+	return windows, nil
+}
+
+func readSamples(path string) ([]Sample, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var samples []Sample
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var sample Sample
+		if err := json.Unmarshal(scanner.Bytes(), &sample); err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+
+	return samples, scanner.Err()
+}
+
+// windowFor returns the average byte rate between the oldest sample still
+// within span of now and the most recent sample. If no sample is within
+// span, it uses the last two samples instead.
+func windowFor(samples []Sample, now time.Time, span time.Duration) Window {
+	if len(samples) < 2 {
+		return Window{}
+	}
+
+	latest := samples[len(samples)-1]
+
+	var earliest *Sample
+	for i := range samples {
+		if now.Sub(samples[i].Time) <= span {
+			earliest = &samples[i]
+			break
+		}
+	}
+	if earliest == nil {
+		earliest = &samples[len(samples)-2]
+	}
+
+	elapsed := latest.Time.Sub(earliest.Time).Seconds()
+	if elapsed <= 0 {
+		return Window{}
+	}
+
+	bytes := (latest.ReceiveBytes + latest.TransmitBytes) - (earliest.ReceiveBytes + earliest.TransmitBytes)
+	return Window{BytesPerSecond: float64(bytes) / elapsed}
+}
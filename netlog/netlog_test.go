@@ -0,0 +1,76 @@
+package netlog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWindowForComputesRate(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	samples := []Sample{
+		{Time: now.Add(-90 * time.Second), ReceiveBytes: 0, TransmitBytes: 0},
+		{Time: now.Add(-30 * time.Second), ReceiveBytes: 1500, TransmitBytes: 1500},
+		{Time: now, ReceiveBytes: 3000, TransmitBytes: 3000},
+	}
+
+	w := windowFor(samples, now, time.Minute)
+
+	// Only the last two samples fall within the 1m window: 3000 bytes
+	// total over 30s.
+	want := 100.0
+	if w.BytesPerSecond != want {
+		t.Errorf("BytesPerSecond = %v, want %v", w.BytesPerSecond, want)
+	}
+}
+
+func TestWindowForInsufficientSamples(t *testing.T) {
+	w := windowFor([]Sample{{Time: time.Unix(0, 0)}}, time.Unix(1, 0), time.Minute)
+	if w != (Window{}) {
+		t.Errorf("windowFor with <2 samples = %+v, want zero value", w)
+	}
+}
+
+func TestAggregateReadsSamplesByHostname(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Unix(2000, 0)
+
+	samples := []Sample{
+		{Time: now.Add(-10 * time.Second), ReceiveBytes: 100, TransmitBytes: 100},
+		{Time: now, ReceiveBytes: 600, TransmitBytes: 600},
+	}
+
+	f, err := os.Create(filepath.Join(dir, "server1.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range samples {
+		line, _ := json.Marshal(s)
+		f.Write(append(line, '\n'))
+	}
+	f.Close()
+
+	windows, err := Aggregate(dir, "server1", now)
+	if err != nil {
+		t.Fatalf("Aggregate: %s", err)
+	}
+
+	got := windows["1m"].BytesPerSecond
+	want := 100.0
+	if got != want {
+		t.Errorf("1m BytesPerSecond = %v, want %v", got, want)
+	}
+
+	// A hostname with no log file yet should aggregate to all zeros, not
+	// an error.
+	empty, err := Aggregate(dir, "no-such-peer", now)
+	if err != nil {
+		t.Fatalf("Aggregate for missing peer: %s", err)
+	}
+	if empty["1m"] != (Window{}) {
+		t.Errorf("missing peer window = %+v, want zero value", empty["1m"])
+	}
+}
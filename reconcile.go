@@ -0,0 +1,128 @@
+package dsnet
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// reconcileInterval is how often the reconciler checks persistent peers for
+// an offline transition worth acting on.
+const reconcileInterval = time.Minute
+
+// keepaliveInterval is pushed alongside a re-resolved endpoint to force a
+// fresh handshake attempt rather than waiting on traffic to trigger one.
+const keepaliveInterval = 25 * time.Second
+
+// deviceConfigurer is the subset of *wgctrl.Client's API the reconciler
+// needs. It's an interface rather than a concrete *wgctrl.Client so tests
+// can exercise reconcileOnce/reconnect with a fake instead of requiring an
+// actual wireguard device.
+type deviceConfigurer interface {
+	ConfigureDevice(name string, cfg wgtypes.Config) error
+}
+
+// PersistentPeerReconciler periodically checks peers marked Persistent and,
+// for each one that is offline and has an Endpoint, re-resolves that
+// endpoint, pushes it into wireguard and pokes it with a keepalive to
+// force a fresh handshake. Online persistent peers are not re-resolved. This is dsnet's equivalent of Tendermint's
+// persistent-peer redial: peers we depend on being reachable shouldn't
+// just sit offline waiting for the other side to dial back in.
+type PersistentPeerReconciler struct {
+	Client        deviceConfigurer
+	InterfaceName string
+	// GetConfig returns the live config; used to look up each persistent
+	// peer's public key by hostname. A func rather than a snapshot since
+	// the daemon's config pointer changes on every SIGHUP reload.
+	GetConfig func() *DsnetConfig
+	// GetReport returns the most recently generated report, used to find
+	// peers that have transitioned to StatusOffline.
+	GetReport func() *DsnetReport
+	// OnResolve, if set, is called after a successful re-resolution so the
+	// caller can fold LastResolutionTime/LastResolvedIP into the next
+	// report.
+	OnResolve func(hostname string, resolvedIP net.IP, at time.Time)
+}
+
+// Run blocks, reconciling persistent peers every reconcileInterval until
+// stop is closed.
+func (r *PersistentPeerReconciler) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			r.reconcileOnce()
+		}
+	}
+}
+
+func (r *PersistentPeerReconciler) reconcileOnce() {
+	report := r.GetReport()
+	if report == nil {
+		return
+	}
+
+	for _, peerReport := range report.Peers {
+		if !peerReport.Persistent || peerReport.Status != StatusOffline || peerReport.Endpoint == "" {
+			continue
+		}
+
+		if err := r.reconnect(peerReport.Hostname, peerReport.Endpoint); err != nil {
+			log.Printf("dsnet: reconnect %s (%s): %s", peerReport.Hostname, peerReport.Endpoint, err)
+		}
+	}
+}
+
+// reconnect re-resolves hostname's endpoint and pushes it into wireguard
+// along with a keepalive nudge to force a fresh handshake.
+func (r *PersistentPeerReconciler) reconnect(hostname, endpoint string) error {
+	peer, ok := findPeer(r.GetConfig(), hostname)
+	if !ok {
+		return fmt.Errorf("no configured peer named %s", hostname)
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", endpoint)
+	if err != nil {
+		return fmt.Errorf("resolving %s: %w", endpoint, err)
+	}
+
+	err = r.Client.ConfigureDevice(r.InterfaceName, wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{
+			{
+				PublicKey:                   peer.PublicKey.Key,
+				UpdateOnly:                  true,
+				Endpoint:                    addr,
+				PersistentKeepaliveInterval: durationPtr(keepaliveInterval),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("configuring device: %w", err)
+	}
+
+	if r.OnResolve != nil {
+		r.OnResolve(hostname, addr.IP, time.Now())
+	}
+
+	return nil
+}
+
+func findPeer(conf *DsnetConfig, hostname string) (Peer, bool) {
+	for _, peer := range conf.Peers {
+		if peer.Hostname == hostname {
+			return peer, true
+		}
+	}
+	return Peer{}, false
+}
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
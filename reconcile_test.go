@@ -0,0 +1,137 @@
+package dsnet
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// fakeDeviceConfigurer records the configs it's asked to apply instead of
+// talking to a real wireguard device.
+type fakeDeviceConfigurer struct {
+	configs []wgtypes.Config
+	err     error
+}
+
+func (f *fakeDeviceConfigurer) ConfigureDevice(name string, cfg wgtypes.Config) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.configs = append(f.configs, cfg)
+	return nil
+}
+
+func TestReconcileOnceReconnectsOfflinePersistentPeers(t *testing.T) {
+	peer := testPeer(t, "server1", "10.0.0.2")
+	peer.Persistent = true
+	peer.Endpoint = "127.0.0.1:51820"
+
+	client := &fakeDeviceConfigurer{}
+
+	var resolvedHostname string
+	var resolvedIP net.IP
+	var resolvedAt time.Time
+
+	r := &PersistentPeerReconciler{
+		Client:        client,
+		InterfaceName: "dsnet0",
+		GetConfig:     func() *DsnetConfig { return &DsnetConfig{Peers: []Peer{peer}} },
+		GetReport: func() *DsnetReport {
+			return &DsnetReport{Peers: []PeerReport{{
+				Hostname:   peer.Hostname,
+				Persistent: true,
+				Endpoint:   peer.Endpoint,
+				Status:     StatusOffline,
+			}}}
+		},
+		OnResolve: func(hostname string, ip net.IP, at time.Time) {
+			resolvedHostname, resolvedIP, resolvedAt = hostname, ip, at
+		},
+	}
+
+	r.reconcileOnce()
+
+	if len(client.configs) != 1 {
+		t.Fatalf("ConfigureDevice called %d times, want 1", len(client.configs))
+	}
+	pushed := client.configs[0].Peers[0]
+	if pushed.PublicKey != peer.PublicKey.Key {
+		t.Errorf("pushed PublicKey = %v, want %v", pushed.PublicKey, peer.PublicKey.Key)
+	}
+	if pushed.Endpoint == nil || pushed.Endpoint.IP.String() != "127.0.0.1" {
+		t.Errorf("pushed Endpoint = %v, want 127.0.0.1:51820", pushed.Endpoint)
+	}
+
+	if resolvedHostname != peer.Hostname {
+		t.Errorf("OnResolve hostname = %q, want %q", resolvedHostname, peer.Hostname)
+	}
+	if resolvedIP.String() != "127.0.0.1" {
+		t.Errorf("OnResolve IP = %v, want 127.0.0.1", resolvedIP)
+	}
+	if resolvedAt.IsZero() {
+		t.Error("OnResolve at should not be zero")
+	}
+}
+
+func TestReconcileOnceSkipsNonCandidatePeers(t *testing.T) {
+	client := &fakeDeviceConfigurer{}
+
+	r := &PersistentPeerReconciler{
+		Client:        client,
+		InterfaceName: "dsnet0",
+		GetConfig:     func() *DsnetConfig { return &DsnetConfig{} },
+		GetReport: func() *DsnetReport {
+			return &DsnetReport{Peers: []PeerReport{
+				// online persistent peer: nothing to reconnect
+				{Hostname: "online", Persistent: true, Endpoint: "a:51820", Status: StatusOnline},
+				// offline but not persistent: not ours to redial
+				{Hostname: "ephemeral", Persistent: false, Endpoint: "b:51820", Status: StatusOffline},
+				// offline persistent but no endpoint configured
+				{Hostname: "no-endpoint", Persistent: true, Endpoint: "", Status: StatusOffline},
+			}}
+		},
+	}
+
+	r.reconcileOnce()
+
+	if len(client.configs) != 0 {
+		t.Errorf("ConfigureDevice called %d times, want 0", len(client.configs))
+	}
+}
+
+func TestReconnectErrorsWithoutConfiguredPeer(t *testing.T) {
+	client := &fakeDeviceConfigurer{}
+
+	r := &PersistentPeerReconciler{
+		Client:        client,
+		InterfaceName: "dsnet0",
+		GetConfig:     func() *DsnetConfig { return &DsnetConfig{} },
+	}
+
+	err := r.reconnect("missing", "127.0.0.1:51820")
+	if err == nil {
+		t.Fatal("reconnect: expected an error for a hostname with no configured peer")
+	}
+	if len(client.configs) != 0 {
+		t.Error("reconnect should not call ConfigureDevice when the peer can't be found")
+	}
+}
+
+func TestReconnectPropagatesConfigureDeviceError(t *testing.T) {
+	peer := testPeer(t, "server1", "10.0.0.2")
+	client := &fakeDeviceConfigurer{err: errors.New("boom")}
+
+	r := &PersistentPeerReconciler{
+		Client:        client,
+		InterfaceName: "dsnet0",
+		GetConfig:     func() *DsnetConfig { return &DsnetConfig{Peers: []Peer{peer}} },
+	}
+
+	err := r.reconnect(peer.Hostname, "127.0.0.1:51820")
+	if err == nil {
+		t.Fatal("reconnect: expected ConfigureDevice's error to propagate")
+	}
+}
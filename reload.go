@@ -0,0 +1,186 @@
+package dsnet
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// ConfigReloader applies config changes on SIGHUP without restarting the
+// daemon or dropping existing handshakes: it diffs the newly-read config
+// against the config it currently holds (GetConfig) and pushes only the
+// add/remove/update deltas through wgctrl. The device's own peer list is
+// not part of the diff.
+type ConfigReloader struct {
+	Client        *wgctrl.Client
+	InterfaceName string
+
+	// GetConfig/SetConfig and GetReport/SetReport give the reloader access
+	// to the daemon's live config and report state.
+	GetConfig func() *DsnetConfig
+	SetConfig func(*DsnetConfig)
+	GetReport func() *DsnetReport
+	SetReport func(*DsnetReport)
+	// NetlogDir is passed through to GenerateReport to populate traffic
+	// aggregates; empty disables them.
+	NetlogDir string
+}
+
+// WatchSIGHUP blocks, calling Reload on every SIGHUP until stop is closed.
+func (r *ConfigReloader) WatchSIGHUP(stop <-chan struct{}) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-sighup:
+			if err := r.Reload(); err != nil {
+				log.Printf("dsnet: config reload: %s", err)
+			}
+		}
+	}
+}
+
+// Reload re-reads the config, applies the delta against conf.Peers to the
+// running wireguard device, and regenerates the report.
+func (r *ConfigReloader) Reload() error {
+	newConf, err := LoadConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	oldConf := r.GetConfig()
+	preserveAdded(oldConf, newConf)
+
+	peerConfigs := diffPeerConfigs(oldConf.Peers, newConf.Peers)
+	if len(peerConfigs) > 0 {
+		err = r.Client.ConfigureDevice(r.InterfaceName, wgtypes.Config{Peers: peerConfigs})
+		if err != nil {
+			return fmt.Errorf("applying peer delta: %w", err)
+		}
+	}
+
+	dev, err := r.Client.Device(r.InterfaceName)
+	if err != nil {
+		return fmt.Errorf("reading device after reload: %w", err)
+	}
+
+	report := GenerateReport(dev, newConf, r.GetReport(), r.NetlogDir)
+	r.SetConfig(newConf)
+	r.SetReport(&report)
+
+	return nil
+}
+
+// preserveAdded copies each peer's original Added timestamp forward from
+// oldConf, since a hand-edited or normalised config shouldn't reset when
+// dsnet considers a peer to have joined.
+func preserveAdded(oldConf, newConf *DsnetConfig) {
+	oldByHostname := make(map[string]Peer, len(oldConf.Peers))
+	for _, peer := range oldConf.Peers {
+		oldByHostname[peer.Hostname] = peer
+	}
+
+	for i, peer := range newConf.Peers {
+		if old, ok := oldByHostname[peer.Hostname]; ok {
+			newConf.Peers[i].Added = old.Added
+		}
+	}
+}
+
+// diffPeerConfigs computes the wgtypes.PeerConfig delta needed to bring the
+// running device's peers from old to new: removed peers are marked
+// Remove, added peers are pushed as plain adds, and changed peers are
+// pushed with UpdateOnly. Both added and changed peers get their
+// AllowedIPs replaced; fields left unset are not touched by wgctrl.
+func diffPeerConfigs(oldPeers, newPeers []Peer) []wgtypes.PeerConfig {
+	oldByKey := make(map[wgtypes.Key]Peer, len(oldPeers))
+	for _, peer := range oldPeers {
+		oldByKey[peer.PublicKey.Key] = peer
+	}
+
+	newByKey := make(map[wgtypes.Key]Peer, len(newPeers))
+	for _, peer := range newPeers {
+		newByKey[peer.PublicKey.Key] = peer
+	}
+
+	var deltas []wgtypes.PeerConfig
+
+	for key, peer := range newByKey {
+		old, existed := oldByKey[key]
+		if existed && peerUnchanged(old, peer) {
+			continue
+		}
+
+		deltas = append(deltas, wgtypes.PeerConfig{
+			PublicKey:         key,
+			UpdateOnly:        existed,
+			ReplaceAllowedIPs: true,
+			AllowedIPs:        PeerAllowedIPs(peer),
+		})
+	}
+
+	for key := range oldByKey {
+		if _, stillPresent := newByKey[key]; !stillPresent {
+			deltas = append(deltas, wgtypes.PeerConfig{
+				PublicKey: key,
+				Remove:    true,
+			})
+		}
+	}
+
+	return deltas
+}
+
+// peerUnchanged reports whether a and b would produce the same wireguard
+// peer config. Endpoint is deliberately excluded: it's a DNS name or
+// "ip:port" that only PersistentPeerReconciler resolves and applies (see
+// reconcile.go), so comparing it here would just cause a reload to push an
+// unresolved hostname as a literal endpoint.
+func peerUnchanged(a, b Peer) bool {
+	return a.IP.Equal(b.IP) && networksEqual(a.Networks, b.Networks)
+}
+
+// networksEqual compares two Networks lists by CIDR contents, not just
+// length, and ignores order.
+func networksEqual(a, b []JSONIPNet) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	counts := make(map[string]int, len(a))
+	for _, network := range a {
+		ipnet := net.IPNet(network)
+		counts[ipnet.String()]++
+	}
+	for _, network := range b {
+		ipnet := net.IPNet(network)
+		s := ipnet.String()
+		if counts[s] == 0 {
+			return false
+		}
+		counts[s]--
+	}
+
+	return true
+}
+
+func PeerAllowedIPs(peer Peer) []net.IPNet {
+	allowedIPs := make([]net.IPNet, 0, len(peer.Networks)+1)
+	allowedIPs = append(allowedIPs, net.IPNet{IP: peer.IP, Mask: net.CIDRMask(32, 32)})
+
+	for _, network := range peer.Networks {
+		allowedIPs = append(allowedIPs, net.IPNet(network))
+	}
+
+	return allowedIPs
+}
@@ -0,0 +1,113 @@
+package dsnet
+
+import (
+	"net"
+	"testing"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func testPeer(t *testing.T, hostname string, ip string, networks ...string) Peer {
+	t.Helper()
+
+	key, err := wgtypes.GenerateKey()
+	if err != nil {
+		t.Fatalf("generating key: %s", err)
+	}
+
+	peer := Peer{
+		PublicKey: PublicKey{Key: key},
+		Hostname:  hostname,
+		IP:        net.ParseIP(ip),
+	}
+
+	for _, cidr := range networks {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatalf("parsing %s: %s", cidr, err)
+		}
+		peer.Networks = append(peer.Networks, JSONIPNet(*ipnet))
+	}
+
+	return peer
+}
+
+func TestNetworksEqualIgnoresOrder(t *testing.T) {
+	_, a, _ := net.ParseCIDR("10.0.0.0/24")
+	_, b, _ := net.ParseCIDR("10.0.1.0/24")
+
+	same := networksEqual(
+		[]JSONIPNet{JSONIPNet(*a), JSONIPNet(*b)},
+		[]JSONIPNet{JSONIPNet(*b), JSONIPNet(*a)},
+	)
+	if !same {
+		t.Error("networksEqual: same networks in different order should be equal")
+	}
+
+	changed := networksEqual(
+		[]JSONIPNet{JSONIPNet(*a)},
+		[]JSONIPNet{JSONIPNet(*b)},
+	)
+	if changed {
+		t.Error("networksEqual: different networks of the same count should not be equal")
+	}
+}
+
+func TestDiffPeerConfigsDetectsNetworkChange(t *testing.T) {
+	peer := testPeer(t, "server1", "10.0.0.2", "10.0.0.0/24")
+
+	changedPeer := peer
+	_, newNet, _ := net.ParseCIDR("10.0.1.0/24")
+	changedPeer.Networks = []JSONIPNet{JSONIPNet(*newNet)}
+
+	deltas := diffPeerConfigs([]Peer{peer}, []Peer{changedPeer})
+	if len(deltas) != 1 {
+		t.Fatalf("diffPeerConfigs: got %d deltas, want 1 (swapping a same-count network should be detected)", len(deltas))
+	}
+	if !deltas[0].UpdateOnly {
+		t.Error("diffPeerConfigs: changed peer should be pushed as UpdateOnly, not re-added")
+	}
+}
+
+func TestDiffPeerConfigsIgnoresEndpointOnlyChange(t *testing.T) {
+	peer := testPeer(t, "server1", "10.0.0.2")
+	peer.Endpoint = "old.example.com:51820"
+
+	changedPeer := peer
+	changedPeer.Endpoint = "new.example.com:51820"
+
+	deltas := diffPeerConfigs([]Peer{peer}, []Peer{changedPeer})
+	if len(deltas) != 0 {
+		t.Fatalf("diffPeerConfigs: got %d deltas, want 0 (Endpoint-only changes are applied by the reconciler, not a reload)", len(deltas))
+	}
+}
+
+func TestDiffPeerConfigsAddAndRemove(t *testing.T) {
+	kept := testPeer(t, "server1", "10.0.0.2")
+	removed := testPeer(t, "server2", "10.0.0.3")
+	added := testPeer(t, "server3", "10.0.0.4")
+
+	deltas := diffPeerConfigs([]Peer{kept, removed}, []Peer{kept, added})
+
+	var sawAdd, sawRemove bool
+	for _, d := range deltas {
+		switch d.PublicKey {
+		case added.PublicKey.Key:
+			sawAdd = true
+			if d.UpdateOnly || d.Remove {
+				t.Error("new peer should be a plain add, not UpdateOnly or Remove")
+			}
+		case removed.PublicKey.Key:
+			sawRemove = true
+			if !d.Remove {
+				t.Error("removed peer should have Remove set")
+			}
+		case kept.PublicKey.Key:
+			t.Error("unchanged peer should not produce a delta")
+		}
+	}
+
+	if !sawAdd || !sawRemove {
+		t.Fatalf("diffPeerConfigs: deltas = %+v, want an add and a remove", deltas)
+	}
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/go-playground/validator/v10"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+	"github.com/herzenschein/dsnet/netlog"
 )
 
 type Status int
@@ -61,7 +63,11 @@ type DsnetReport struct {
 	Peers       []PeerReport
 }
 
-func GenerateReport(dev *wgtypes.Device, conf *DsnetConfig, oldReport *DsnetReport) DsnetReport {
+// GenerateReport builds a fresh report from the live wireguard device and
+// config. netlogDir enables per-peer traffic aggregates (Traffic1m/5m/1h)
+// from the dsnet/netlog on-disk samples; pass "" to leave them zero, eg
+// when netlog isn't configured.
+func GenerateReport(dev *wgtypes.Device, conf *DsnetConfig, oldReport *DsnetReport, netlogDir string) DsnetReport {
 	wgPeerIndex := make(map[wgtypes.Key]wgtypes.Peer)
 	peerReports := make([]PeerReport, len(conf.Peers))
 	oldPeerReportIndex := make(map[string]PeerReport)
@@ -98,6 +104,8 @@ func GenerateReport(dev *wgtypes.Device, conf *DsnetConfig, oldReport *DsnetRepo
 			externalIP = wgPeer.Endpoint.IP
 		}
 
+		old, hadOld := oldPeerReportIndex[peer.Hostname]
+
 		peerReports[i] = PeerReport{
 			Hostname:          peer.Hostname,
 			Owner:             peer.Owner,
@@ -112,6 +120,24 @@ func GenerateReport(dev *wgtypes.Device, conf *DsnetConfig, oldReport *DsnetRepo
 			TransmitBytes:     wgPeer.TransmitBytes,
 			ReceiveBytesSI:    BytesToSI(wgPeer.ReceiveBytes),
 			TransmitBytesSI:   BytesToSI(wgPeer.TransmitBytes),
+			Persistent:        peer.Persistent,
+			Endpoint:          peer.Endpoint,
+		}
+
+		// DNS resolution for persistent peers happens out-of-band in the
+		// reconciliation loop (see reconcile.go), so carry the last known
+		// resolution forward across reports rather than losing it here.
+		if hadOld {
+			peerReports[i].LastResolutionTime = old.LastResolutionTime
+			peerReports[i].LastResolvedIP = old.LastResolvedIP
+		}
+
+		if netlogDir != "" {
+			if windows, err := netlog.Aggregate(netlogDir, peer.Hostname, time.Now()); err == nil {
+				peerReports[i].Traffic1m = TrafficWindow(windows["1m"])
+				peerReports[i].Traffic5m = TrafficWindow(windows["5m"])
+				peerReports[i].Traffic1h = TrafficWindow(windows["1h"])
+			}
 		}
 	}
 
@@ -179,4 +205,28 @@ type PeerReport struct {
 	TransmitBytes     int64
 	ReceiveBytesSI    string
 	TransmitBytesSI   string
+	// Persistent and Endpoint mirror the peer's config; see Peer in
+	// config.go.
+	Persistent bool
+	Endpoint   string
+	// LastResolutionTime and LastResolvedIP record the most recent DNS
+	// lookup of Endpoint performed by the persistent-peer reconciler, so
+	// operators can see DNS churn even though the lookup itself happens
+	// outside of GenerateReport.
+	LastResolutionTime time.Time
+	LastResolvedIP     net.IP
+	// Traffic1m/5m/1h are traffic rate aggregates computed by the
+	// dsnet/netlog package from its on-disk sample log, which holds byte
+	// counters only, so PacketsPerSecond is always zero. Zero when netlog
+	// isn't enabled or a peer has no log file yet.
+	Traffic1m TrafficWindow
+	Traffic5m TrafficWindow
+	Traffic1h TrafficWindow
+}
+
+// TrafficWindow is a traffic rate averaged over a trailing time span. See
+// dsnet/netlog.Window, which this mirrors for inclusion in PeerReport.
+type TrafficWindow struct {
+	BytesPerSecond   float64
+	PacketsPerSecond float64
 }
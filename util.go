@@ -0,0 +1,48 @@
+package dsnet
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// CONFIG_FILE is the canonical location of the dsnet config on disk.
+const CONFIG_FILE = "/etc/dsnet/dsnet.json"
+
+// TIMEOUT is the handshake age after which a peer is considered offline.
+const TIMEOUT = time.Minute * 3
+
+// EXPIRY is the handshake age after which a peer is considered dormant and
+// eligible for removal.
+const EXPIRY = time.Hour * 24 * 28
+
+// check panics on unexpected, unrecoverable errors. Callers that can
+// produce actionable operator-facing errors should use ExitFail instead.
+func check(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ExitFail prints a formatted error message to stderr and exits 1. Used for
+// operator-facing errors where a stack trace would not help.
+func ExitFail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
+
+// BytesToSI renders a byte count using SI (base 1000) suffixes, eg 1.2MB.
+func BytesToSI(b int64) string {
+	const unit = 1000
+	if b < unit {
+		return fmt.Sprintf("%dB", b)
+	}
+
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f%cB", float64(b)/float64(div), "kMGTPE"[exp])
+}